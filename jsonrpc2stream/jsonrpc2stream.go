@@ -0,0 +1,461 @@
+// Package jsonrpc2stream runs JSON-RPC 2.0 over a persistent
+// io.ReadWriteCloser (a TCP connection, a websocket, stdio, ...), as opposed
+// to the one-shot request/response model used by lrpchttp. Unlike an http
+// based transport, both sides of a Conn may call methods on the other at any
+// time, and many calls may be in flight concurrently in either direction.
+//
+// A Conn is constructed around an io.ReadWriteCloser and an lrpc.Handler which
+// will be used to serve calls initiated by the remote peer:
+//
+//	conn := jsonrpc2stream.NewConn(rwc, myHandler)
+//	defer conn.Close()
+//
+//	var reply string
+//	err := conn.Call(ctx, "Echo", "hello", &reply)
+//
+// Messages are framed using NewlineFramer by default (one JSON object per
+// line), but ContentLengthFramer can be used instead via WithFramer to
+// interoperate with protocols like LSP which frame messages with a
+// Content-Length header.
+//
+// Cancellation of a Call propagates to the remote peer as a "$/cancelRequest"
+// notification (the same convention used by LSP), which Conn uses on the
+// receiving end to cancel the Context passed to the in-progress Handler.
+package jsonrpc2stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/levenlabs/lrpc"
+)
+
+// CancelMethod is the notification method sent to request cancellation of an
+// in-flight call on the remote peer.
+const CancelMethod = "$/cancelRequest"
+
+// These mirror the JSON RPC2 reserved error codes used by json2.ErrNoMethod
+// and json2.ErrServer, so that lrpc.ErrMethodNotFound and other plain errors
+// returned by a Handler shared between lrpchttp and a Conn are reported with
+// the same code over either transport. Unlike json2, a Conn does not map json
+// decoding errors to the invalid params code, and reports a *json2.Error with
+// the generic server error code.
+const (
+	errCodeNoMethod = -32601
+	errCodeServer   = -32000
+)
+
+// errToError converts an error returned by a Handler into the Error which
+// should be sent back over the wire, mapping lrpc.ErrMethodNotFound to its
+// reserved JSON RPC2 code rather than the generic server error code.
+func errToError(err error) *Error {
+	if jerr, ok := err.(*Error); ok {
+		return jerr
+	}
+	if errors.Is(err, lrpc.ErrMethodNotFound) {
+		return &Error{Code: errCodeNoMethod, Message: err.Error()}
+	}
+	return &Error{Code: errCodeServer, Message: err.Error()}
+}
+
+// Error is the JSON-RPC2 error object, returned from Call when the remote
+// peer's Handler returned an error.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// message is the wire format for every object sent over a Conn: requests,
+// notifications, and responses are all decoded into one of these and told
+// apart by which of their fields are set.
+type message struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method,omitempty"`
+	Params  *json.RawMessage `json:"params,omitempty"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *Error           `json:"error,omitempty"`
+
+	// idSet records whether the id field was present in the JSON this
+	// message was decoded from. ID alone can't tell an explicit "id":null
+	// apart from an omitted id, but only the latter makes a request a
+	// notification; it's set by UnmarshalJSON.
+	idSet bool
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, so idSet can be
+// derived from whether the id key was present in b.
+func (m *message) UnmarshalJSON(b []byte) error {
+	type plain message
+	if err := json.Unmarshal(b, (*plain)(m)); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	_, m.idSet = raw["id"]
+	return nil
+}
+
+func idKey(id *json.RawMessage) string {
+	if id == nil {
+		return ""
+	}
+	return string(*id)
+}
+
+// Framer describes how individual JSON-RPC2 messages are delimited on the
+// wire. See NewlineFramer and ContentLengthFramer for the two framings Conn
+// supports out of the box.
+type Framer interface {
+	// ReadFrame reads and returns the bytes of the next full message from r.
+	ReadFrame(r *bufio.Reader) ([]byte, error)
+
+	// WriteFrame writes a single message's bytes to w, including whatever
+	// delimiter the framing requires.
+	WriteFrame(w io.Writer, b []byte) error
+}
+
+type newlineFramer struct{}
+
+func (newlineFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	return r.ReadBytes('\n')
+}
+
+func (newlineFramer) WriteFrame(w io.Writer, b []byte) error {
+	_, err := w.Write(append(b, '\n'))
+	return err
+}
+
+// NewlineFramer frames each message as a single JSON object followed by a
+// newline. It's the default Framer used by NewConn.
+var NewlineFramer Framer = newlineFramer{}
+
+type contentLengthFramer struct{}
+
+func (contentLengthFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		i := strings.Index(line, ":")
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(line[:i]), "Content-Length") {
+			continue
+		}
+		length, err = strconv.Atoi(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("jsonrpc2stream: bad Content-Length header %q: %s", line, err)
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("jsonrpc2stream: message is missing a Content-Length header")
+	}
+
+	b := make([]byte, length)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func (contentLengthFramer) WriteFrame(w io.Writer, b []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(b)); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// ContentLengthFramer frames each message with a Content-Length header
+// followed by a blank line, as used by the Language Server Protocol.
+var ContentLengthFramer Framer = contentLengthFramer{}
+
+// Option customizes a Conn constructed by NewConn.
+type Option func(*Conn)
+
+// WithFramer overrides the Framer used to delimit messages on the wire. The
+// default, if this option isn't given, is NewlineFramer.
+func WithFramer(f Framer) Option {
+	return func(c *Conn) { c.framer = f }
+}
+
+// Conn runs JSON-RPC2 over a persistent io.ReadWriteCloser, allowing either
+// side to call methods on the other, with many calls in flight concurrently
+// in either direction.
+//
+// A Conn must be constructed with NewConn.
+type Conn struct {
+	rwc    io.ReadWriteCloser
+	framer Framer
+	h      lrpc.Handler
+
+	writeMu sync.Mutex
+
+	idCounter uint64
+
+	mu       sync.Mutex
+	pending  map[string]chan *message
+	handling map[string]context.CancelFunc
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewConn wraps rwc in a Conn, using h to serve calls initiated by the remote
+// peer (h may be nil if this side never expects to receive any), and begins
+// reading incoming messages in a background goroutine.
+func NewConn(rwc io.ReadWriteCloser, h lrpc.Handler, opts ...Option) *Conn {
+	c := &Conn{
+		rwc:      rwc,
+		framer:   NewlineFramer,
+		h:        h,
+		pending:  map[string]chan *message{},
+		handling: map[string]context.CancelFunc{},
+		closed:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes the underlying io.ReadWriteCloser, and causes any blocked
+// calls to Call to return with an error.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.rwc.Close()
+}
+
+// readLoop reads and demuxes incoming messages until the underlying
+// ReadWriteCloser returns an error, at which point the Conn is closed.
+func (c *Conn) readLoop() {
+	defer c.Close()
+
+	r := bufio.NewReader(c.rwc)
+	for {
+		b, err := c.framer.ReadFrame(r)
+		if err != nil {
+			return
+		}
+
+		var m message
+		if err := json.Unmarshal(b, &m); err != nil {
+			// malformed frame, nothing sane to do but drop it and keep going
+			continue
+		}
+		c.handleMessage(&m)
+	}
+}
+
+func (c *Conn) handleMessage(m *message) {
+	if m.Method == "" {
+		// this is a response to one of our own outgoing calls
+		key := idKey(m.ID)
+		c.mu.Lock()
+		ch, ok := c.pending[key]
+		delete(c.pending, key)
+		c.mu.Unlock()
+		if ok {
+			ch <- m
+		}
+		return
+	}
+
+	if m.Method == CancelMethod {
+		c.handleCancel(m)
+		return
+	}
+
+	// handled in its own goroutine so a long-running call doesn't block the
+	// reader from demuxing the rest of the stream
+	go c.serveInbound(m)
+}
+
+func (c *Conn) handleCancel(m *message) {
+	var p struct {
+		ID *json.RawMessage `json:"id"`
+	}
+	if m.Params != nil {
+		if err := json.Unmarshal(*m.Params, &p); err != nil {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	cancel, ok := c.handling[idKey(p.ID)]
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (c *Conn) serveInbound(m *message) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	isNotification := !m.idSet
+	if !isNotification {
+		key := idKey(m.ID)
+		c.mu.Lock()
+		c.handling[key] = cancel
+		c.mu.Unlock()
+		defer func() {
+			c.mu.Lock()
+			delete(c.handling, key)
+			c.mu.Unlock()
+		}()
+	}
+
+	if c.h == nil {
+		if !isNotification {
+			c.writeMessage(&message{
+				Version: "2.0",
+				ID:      m.ID,
+				Error:   errToError(lrpc.ErrMethodNotFound),
+			})
+		}
+		return
+	}
+
+	res := c.h.ServeRPC(inboundCall{ctx: ctx, m: m, isNotification: isNotification})
+	if isNotification {
+		return
+	}
+
+	out := &message{Version: "2.0", ID: m.ID}
+	if err, ok := res.(error); ok {
+		out.Error = errToError(err)
+	} else {
+		b, err := json.Marshal(res)
+		if err != nil {
+			out.Error = &Error{Code: -32603, Message: err.Error()}
+		} else {
+			rm := json.RawMessage(b)
+			out.Result = &rm
+		}
+	}
+	c.writeMessage(out)
+}
+
+func (c *Conn) writeMessage(m *message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.framer.WriteFrame(c.rwc, b)
+}
+
+func (c *Conn) nextID() *json.RawMessage {
+	n := atomic.AddUint64(&c.idCounter, 1)
+	rm := json.RawMessage(strconv.FormatUint(n, 10))
+	return &rm
+}
+
+// Call sends method and params to the remote peer as a request, and blocks
+// until a response is received, ctx is cancelled, or the Conn is closed. If
+// result is non-nil the response's result is unmarshalled into it.
+//
+// If ctx is cancelled before a response arrives, a CancelMethod notification
+// is sent to the peer so it can cancel the corresponding in-progress Handler,
+// and ctx.Err() is returned.
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	pm, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	prm := json.RawMessage(pm)
+	id := c.nextID()
+
+	ch := make(chan *message, 1)
+	key := idKey(id)
+	c.mu.Lock()
+	c.pending[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.writeMessage(&message{Version: "2.0", Method: method, Params: &prm, ID: id}); err != nil {
+		return err
+	}
+
+	select {
+	case res := <-ch:
+		if res.Error != nil {
+			return res.Error
+		}
+		if result != nil && res.Result != nil {
+			return json.Unmarshal(*res.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		c.Notify(context.Background(), CancelMethod, map[string]*json.RawMessage{"id": id})
+		return ctx.Err()
+	case <-c.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+// Notify sends method and params to the remote peer as a notification, which
+// the peer will execute but never reply to.
+func (c *Conn) Notify(ctx context.Context, method string, params interface{}) error {
+	pm, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	prm := json.RawMessage(pm)
+	return c.writeMessage(&message{Version: "2.0", Method: method, Params: &prm})
+}
+
+// inboundCall implements lrpc.Call (and lrpc.Notifier) for a message received
+// from the remote peer.
+type inboundCall struct {
+	ctx            context.Context
+	m              *message
+	isNotification bool
+}
+
+func (ic inboundCall) Context() context.Context {
+	return ic.ctx
+}
+
+func (ic inboundCall) Method() string {
+	return ic.m.Method
+}
+
+func (ic inboundCall) UnmarshalArgs(i interface{}) error {
+	if ic.m.Params == nil {
+		return nil
+	}
+	return json.Unmarshal(*ic.m.Params, i)
+}
+
+// IsNotification implements the lrpc.Notifier interface.
+func (ic inboundCall) IsNotification() bool {
+	return ic.isNotification
+}
@@ -0,0 +1,134 @@
+package jsonrpc2stream
+
+import (
+	"context"
+	"errors"
+	"net"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/levenlabs/lrpc"
+)
+
+func newConnPair(h lrpc.Handler) (server, client *Conn) {
+	a, b := net.Pipe()
+	return NewConn(a, h), NewConn(b, nil)
+}
+
+func TestConnCall(t *T) {
+	echo := lrpc.HandlerFunc(func(c lrpc.Call) interface{} {
+		var s string
+		if err := c.UnmarshalArgs(&s); err != nil {
+			return err
+		}
+		return s
+	})
+	server, client := newConnPair(echo)
+	defer server.Close()
+	defer client.Close()
+
+	var reply string
+	err := client.Call(context.Background(), "Echo", "hello", &reply)
+	require.Nil(t, err)
+	assert.Equal(t, "hello", reply)
+}
+
+func TestConnCallError(t *T) {
+	h := lrpc.HandlerFunc(func(lrpc.Call) interface{} {
+		return errors.New("nope")
+	})
+	server, client := newConnPair(h)
+	defer server.Close()
+	defer client.Close()
+
+	err := client.Call(context.Background(), "Fail", nil, nil)
+	require.NotNil(t, err)
+	assert.Equal(t, "nope", err.Error())
+}
+
+func TestConnCallMethodNotFound(t *T) {
+	mux := lrpc.ServeMux{}
+	server, client := newConnPair(mux)
+	defer server.Close()
+	defer client.Close()
+
+	err := client.Call(context.Background(), "NoSuchMethod", nil, nil)
+	require.NotNil(t, err)
+
+	jerr, ok := err.(*Error)
+	require.True(t, ok, "expected a *Error, got %T", err)
+	assert.Equal(t, errCodeNoMethod, jerr.Code)
+	assert.Equal(t, lrpc.ErrMethodNotFound.Error(), jerr.Message)
+}
+
+func TestConnCallNoHandler(t *T) {
+	server, client := newConnPair(nil)
+	defer server.Close()
+	defer client.Close()
+
+	err := client.Call(context.Background(), "Anything", nil, nil)
+	require.NotNil(t, err)
+
+	jerr, ok := err.(*Error)
+	require.True(t, ok, "expected a *Error, got %T", err)
+	assert.Equal(t, errCodeNoMethod, jerr.Code)
+}
+
+func TestConnNotify(t *T) {
+	got := make(chan string, 1)
+	h := lrpc.HandlerFunc(func(c lrpc.Call) interface{} {
+		var s string
+		c.UnmarshalArgs(&s)
+		got <- s
+		return nil
+	})
+	server, client := newConnPair(h)
+	defer server.Close()
+	defer client.Close()
+
+	require.Nil(t, client.Notify(context.Background(), "Ping", "hello"))
+
+	select {
+	case s := <-got:
+		assert.Equal(t, "hello", s)
+	case <-time.After(time.Second):
+		t.Fatal("notification was never handled")
+	}
+}
+
+func TestConnCallCancellation(t *T) {
+	handlerCtxDone := make(chan struct{}, 1)
+	h := lrpc.HandlerFunc(func(c lrpc.Call) interface{} {
+		<-c.Context().Done()
+		handlerCtxDone <- struct{}{}
+		return errors.New("cancelled")
+	})
+	server, client := newConnPair(h)
+	defer server.Close()
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Call(ctx, "Slow", nil, nil)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("Call never returned after cancellation")
+	}
+
+	select {
+	case <-handlerCtxDone:
+	case <-time.After(time.Second):
+		t.Fatal("handler's Context was never cancelled")
+	}
+}
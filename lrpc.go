@@ -78,6 +78,16 @@ type Call interface {
 	UnmarshalArgs(interface{}) error
 }
 
+// Notifier is an optional interface a Call may implement to indicate that it
+// is a notification, i.e. a call whose result will never actually be sent
+// back to whoever made it. Handlers can type-assert a Call against this
+// interface to short-circuit any expensive work that would only go towards
+// building a result which is guaranteed to be discarded.
+type Notifier interface {
+	// IsNotification returns true if the Call is a notification.
+	IsNotification() bool
+}
+
 // Handler describes a type which can process incoming rpc requests and return a
 // response to them
 type Handler interface {
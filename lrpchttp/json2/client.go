@@ -0,0 +1,188 @@
+package json2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"context"
+)
+
+// IDGenerator produces the id used for a single Client call.
+type IDGenerator func() (json.RawMessage, error)
+
+// HexIDGenerator is an IDGenerator which produces a random 16-byte,
+// hex-encoded id on every call. It's the default used by NewClient.
+func HexIDGenerator() (json.RawMessage, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+// MonotonicIDGenerator returns an IDGenerator which produces sequential
+// integer ids, starting at 1, for callers who'd rather have compact,
+// predictable ids than random ones.
+func MonotonicIDGenerator() IDGenerator {
+	var n uint64
+	return func() (json.RawMessage, error) {
+		return json.Marshal(atomic.AddUint64(&n, 1))
+	}
+}
+
+// ClientCodec is the transport by which a Client actually delivers a Request
+// and retrieves its Response. The default ClientCodec used by NewClient sends
+// the Request as an HTTP POST, but a ClientCodec could just as well run over
+// any other transport, e.g. an io.ReadWriteCloser used for a websocket or
+// stdio connection.
+type ClientCodec interface {
+	// Do sends req to the server. If req.ID is nil then req is a notification
+	// and no response is expected, so res can be ignored. Otherwise Do must
+	// decode the server's Response into res.
+	//
+	// Per the Response docstring, res.Result will already have been set to
+	// the result value the caller is expecting (which may be nil), so that
+	// the response's result is decoded directly into it.
+	Do(ctx context.Context, req *Request, res *Response) error
+}
+
+// httpClientCodec is the default ClientCodec, sending Requests as HTTP POSTs
+// to a fixed endpoint.
+type httpClientCodec struct {
+	endpoint string
+	hc       *http.Client
+}
+
+func (hcc httpClientCodec) Do(ctx context.Context, req *Request, res *Response) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest("POST", hcc.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpRes, err := hcc.hc.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpRes.Body.Close()
+
+	if req.ID == nil {
+		_, err := io.Copy(ioutil.Discard, httpRes.Body)
+		return err
+	}
+	return json.NewDecoder(httpRes.Body).Decode(res)
+}
+
+// ClientOption customizes a Client constructed by NewClient.
+type ClientOption func(*Client)
+
+// WithIDGenerator overrides the IDGenerator used to produce request ids. The
+// default is HexIDGenerator.
+func WithIDGenerator(gen IDGenerator) ClientOption {
+	return func(c *Client) { c.idGen = gen }
+}
+
+// WithHTTPClient overrides the *http.Client used by the default, HTTP based
+// ClientCodec. It has no effect if WithClientCodec is also given.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) { c.hc = hc }
+}
+
+// WithClientCodec overrides the ClientCodec used to actually perform calls,
+// letting a Client run over a transport other than HTTP.
+func WithClientCodec(codec ClientCodec) ClientOption {
+	return func(c *Client) { c.codec = codec }
+}
+
+// Client performs JSON RPC2 calls against a single endpoint.
+//
+//	client := json2.NewClient("http://localhost:8080/rpc")
+//
+//	var reply string
+//	err := client.Call(context.Background(), "Echo", "hello", &reply)
+//
+type Client struct {
+	idGen IDGenerator
+	hc    *http.Client
+	codec ClientCodec
+}
+
+// NewClient returns a Client which, by default, sends calls as HTTP POSTs to
+// endpoint. Use WithClientCodec to run over a different transport instead.
+func NewClient(endpoint string, opts ...ClientOption) *Client {
+	c := &Client{
+		idGen: HexIDGenerator,
+		hc:    http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.codec == nil {
+		c.codec = httpClientCodec{endpoint: endpoint, hc: c.hc}
+	}
+	return c
+}
+
+// Call marshals method and params into a Request, sends it using the Client's
+// ClientCodec, and unmarshals the Response's result into result (which may be
+// nil if the caller doesn't care about it). If the Response carries an error
+// it is returned as-is; since it's a *Error it satisfies the error interface,
+// and callers can inspect it with IsErrorCode.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	req, err := c.newRequest(method, params, true)
+	if err != nil {
+		return err
+	}
+
+	res := Response{Result: result}
+	if err := c.codec.Do(ctx, &req, &res); err != nil {
+		return err
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	return nil
+}
+
+// Notify marshals method and params into a Request with no id, and sends it
+// using the Client's ClientCodec. Per the JSON RPC2 spec a notification gets
+// no reply, so no result is decoded. Notify returns once the ClientCodec's Do
+// does; with the default HTTP ClientCodec that's after the server's HTTP
+// response has been received and its body discarded.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	req, err := c.newRequest(method, params, false)
+	if err != nil {
+		return err
+	}
+	return c.codec.Do(ctx, &req, nil)
+}
+
+func (c *Client) newRequest(method string, params interface{}, wantID bool) (Request, error) {
+	pb, err := json.Marshal(params)
+	if err != nil {
+		return Request{}, err
+	}
+	pr := json.RawMessage(pb)
+
+	req := Request{Version: "2.0", Method: method, Params: &pr}
+	if wantID {
+		id, err := c.idGen()
+		if err != nil {
+			return Request{}, err
+		}
+		req.ID = &id
+	}
+	return req, nil
+}
@@ -0,0 +1,59 @@
+package json2
+
+import (
+	"context"
+	"net/http/httptest"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/levenlabs/lrpc"
+	"github.com/levenlabs/lrpc/lrpchttp"
+)
+
+func TestClientCall(t *T) {
+	notified := make(chan string, 1)
+	srv := httptest.NewServer(lrpchttp.HTTPHandler(Codec{}, lrpc.ServeMux{}.
+		HandleFunc("Echo", func(c lrpc.Call) interface{} {
+			var s string
+			c.UnmarshalArgs(&s)
+			return s
+		}).
+		HandleFunc("Fail", func(lrpc.Call) interface{} {
+			return NewError(ErrInvalidParams, "bad params", nil)
+		}).
+		HandleFunc("Notify", func(c lrpc.Call) interface{} {
+			var s string
+			c.UnmarshalArgs(&s)
+			notified <- s
+			return nil
+		}),
+	))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+
+	var reply string
+	require.Nil(t, client.Call(context.Background(), "Echo", "hello", &reply))
+	assert.Equal(t, "hello", reply)
+
+	err := client.Call(context.Background(), "Fail", "hello", &reply)
+	require.NotNil(t, err)
+	assert.True(t, IsErrorCode(err, ErrInvalidParams))
+
+	require.Nil(t, client.Notify(context.Background(), "Notify", "hi"))
+	assert.Equal(t, "hi", <-notified)
+}
+
+func TestClientMonotonicIDGenerator(t *T) {
+	gen := MonotonicIDGenerator()
+
+	id1, err := gen()
+	require.Nil(t, err)
+	id2, err := gen()
+	require.Nil(t, err)
+
+	assert.Equal(t, "1", string(id1))
+	assert.Equal(t, "2", string(id2))
+}
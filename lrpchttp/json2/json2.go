@@ -3,9 +3,13 @@
 package json2
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/levenlabs/lrpc"
@@ -52,6 +56,32 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+// NewError returns an Error with the given code, message, and optional data.
+func NewError(code ErrCode, msg string, data interface{}) *Error {
+	return &Error{Code: code, Message: msg, Data: data}
+}
+
+// ErrInvalidParamsf is a convenience for constructing an Error with the
+// ErrInvalidParams code and a formatted message.
+func ErrInvalidParamsf(format string, args ...interface{}) *Error {
+	return NewError(ErrInvalidParams, fmt.Sprintf(format, args...), nil)
+}
+
+// IsErrorCode returns true if err is, or wraps (per errors.As), an *Error
+// whose Code is one of codes.
+func IsErrorCode(err error, codes ...ErrCode) bool {
+	var jerr *Error
+	if !errors.As(err, &jerr) {
+		return false
+	}
+	for _, code := range codes {
+		if jerr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
 // Response implements a response object for the JSON RPC2 protocol
 //
 // If being used as part of a client request/response, before unmarshalling the
@@ -80,10 +110,38 @@ type Request struct {
 	// A structured value to pass as arguments to the method.
 	Params *json.RawMessage `json:"params"`
 
-	// The request id. MUST be a string, number or null.
+	// The request id. MUST be a string, number or null. A nil ID is omitted
+	// from the marshalled JSON entirely (making the request a notification);
+	// to send an explicit "id":null, set ID to a RawMessage containing the
+	// literal `null`.
 	// Our implementation will not do type checking for id.
 	// It will be copied as it is.
-	ID *json.RawMessage `json:"id"`
+	ID *json.RawMessage `json:"id,omitempty"`
+
+	// idSet records whether the id field was present in the JSON this Request
+	// was decoded from, which ID alone can't: an explicit "id":null and an
+	// omitted id both decode ID to nil, but only the latter is a
+	// notification per the spec. It's set by UnmarshalJSON and by
+	// NewRequest; Requests built directly via a struct literal default to
+	// idSet false (i.e. a notification) unless set explicitly.
+	idSet bool
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It's defined
+// explicitly so idSet can be derived from whether the id key was present in
+// b, which a plain struct decode can't tell apart from an explicit null.
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type plain Request
+	if err := json.Unmarshal(b, (*plain)(r)); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	_, r.idSet = raw["id"]
+	return nil
 }
 
 // NewRequest encodes the method and its parameters into a new Request object,
@@ -97,6 +155,7 @@ func NewRequest(method string, params interface{}) (Request, error) {
 	r := Request{
 		Version: "2.0",
 		Method:  method,
+		idSet:   true,
 	}
 
 	{
@@ -138,6 +197,14 @@ func (c call) UnmarshalArgs(i interface{}) error {
 	return json.Unmarshal(*c.req.Params, i)
 }
 
+// IsNotification implements the lrpc.Notifier interface. A call is a
+// notification if its Request's id was absent entirely, per the JSON RPC2
+// spec — an explicit "id":null is a (discouraged, but valid) non-notification
+// request which still expects a reply.
+func (c call) IsNotification() bool {
+	return !c.req.idSet
+}
+
 type ctxKey int
 
 const ctxRequest ctxKey = 0
@@ -172,19 +239,120 @@ func (Codec) Respond(cc lrpc.Call, i interface{}) error {
 	w := lrpchttp.ContextResponseWriter(cc.Context())
 	c := cc.(call)
 
+	if c.IsNotification() {
+		// per the JSON RPC2 spec, the server must not reply to a notification
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	res := responseFor(c, i)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	return json.NewEncoder(w).Encode(&res)
+}
+
+// responseFor builds the Response object which should be sent back for the
+// given call and the result returned for it by the Handler.
+func responseFor(c call, i interface{}) Response {
 	var res Response
 	if err, ok := i.(error); ok {
-		jerr, ok := i.(*Error)
-		if !ok {
-			jerr = &Error{Code: ErrServer, Message: err.Error()}
-		}
-		res.Error = jerr
+		res.Error = errToError(err)
 	} else {
 		res.Result = i
 	}
 	res.Version = "2.0"
 	res.ID = c.req.ID
+	return res
+}
+
+// errToError converts any error returned by a Handler into an Error, mapping
+// well-known error types/values to their corresponding JSON RPC2 reserved
+// error codes, and otherwise falling back to ErrServer.
+func errToError(err error) *Error {
+	if jerr, ok := err.(*Error); ok {
+		return jerr
+	}
+
+	if errors.Is(err, lrpc.ErrMethodNotFound) {
+		return NewError(ErrNoMethod, err.Error(), nil)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return NewError(ErrInvalidParams, err.Error(), nil)
+	}
+
+	return NewError(ErrServer, err.Error(), nil)
+}
+
+// NewBatchCall implements the lrpchttp.BatchCodec interface. The incoming
+// request is only treated as a batch if its body, sans leading whitespace,
+// begins with '['; otherwise ok is returned false so NewCall can be used on
+// the (unconsumed) request instead.
+func (codec Codec) NewBatchCall(ctx context.Context, w http.ResponseWriter, r *http.Request) ([]lrpc.Call, bool, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		// not a batch, let NewCall read the body itself
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return nil, false, nil
+	}
+
+	var reqs []Request
+	if err := json.Unmarshal(trimmed, &reqs); err != nil {
+		return nil, true, err
+	}
+
+	if len(reqs) == 0 {
+		// per the spec, a batch which contains no calls at all gets a single
+		// ErrInvalidRequest object back, rather than an (empty) array
+		res := Response{
+			Version: "2.0",
+			Error:   &Error{Code: ErrInvalidRequest, Message: "invalid Request"},
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(&res); err != nil {
+			return nil, true, err
+		}
+		return nil, true, nil
+	}
+
+	calls := make([]lrpc.Call, len(reqs))
+	for i := range reqs {
+		c := call{req: reqs[i]}
+		c.ctx = context.WithValue(ctx, ctxRequest, &c.req)
+		calls[i] = c
+	}
+	return calls, true, nil
+}
+
+// RespondBatch implements the lrpchttp.BatchCodec interface
+func (Codec) RespondBatch(calls []lrpc.Call, ress []interface{}) error {
+	w := lrpchttp.ContextResponseWriter(calls[0].Context())
+
+	resArr := make([]Response, 0, len(calls))
+	for i, cc := range calls {
+		c := cc.(call)
+		if c.IsNotification() {
+			// per the JSON RPC2 spec, notifications are omitted from the
+			// batch's response array entirely
+			continue
+		}
+		resArr = append(resArr, responseFor(c, ress[i]))
+	}
+
+	if len(resArr) == 0 {
+		// every call in the batch was a notification, so there's nothing to
+		// reply with at all
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	return json.NewEncoder(w).Encode(&res)
+	return json.NewEncoder(w).Encode(&resArr)
 }
@@ -36,8 +36,12 @@ var h = lrpchttp.HTTPHandler(Codec{}, lrpc.ServeMux{}.
 		return err2
 	}).
 	HandleFunc("ContextRequest", func(c lrpc.Call) interface{} {
-		r := ContextRequest(c.GetContext())
+		r := ContextRequest(c.Context())
 		return string(*r.Params)
+	}).
+	HandleFunc("BadParams", func(c lrpc.Call) interface{} {
+		var i int
+		return c.UnmarshalArgs(&i)
 	}),
 )
 
@@ -87,4 +91,134 @@ func TestJSON2Codec(t *T) {
 
 	res = requireJSON2Req("ContextRequest", args)
 	assert.Equal(t, `{"foo":"bar"}`, res)
+
+	res = requireJSON2Req("BadParams", args)
+	assert.Equal(t, &Error{Code: ErrInvalidParams, Message: res.(*Error).Message}, res)
+
+	res = requireJSON2Req("NoSuchMethod", args)
+	assert.Equal(t, &Error{Code: ErrNoMethod, Message: lrpc.ErrMethodNotFound.Error()}, res)
+}
+
+func TestNewError(t *T) {
+	err := NewError(ErrInvalidParams, "bad params", map[string]int{"n": 1})
+	assert.Equal(t, &Error{Code: ErrInvalidParams, Message: "bad params", Data: map[string]int{"n": 1}}, err)
+}
+
+func TestErrInvalidParamsf(t *T) {
+	err := ErrInvalidParamsf("expected %d args, got %d", 2, 1)
+	assert.Equal(t, &Error{Code: ErrInvalidParams, Message: "expected 2 args, got 1"}, err)
+}
+
+func TestIsErrorCode(t *T) {
+	err := NewError(ErrNoMethod, "nope", nil)
+	assert.True(t, IsErrorCode(err, ErrNoMethod))
+	assert.True(t, IsErrorCode(err, ErrInvalidParams, ErrNoMethod))
+	assert.False(t, IsErrorCode(err, ErrInvalidParams))
+	assert.False(t, IsErrorCode(errors.New("not a json2 error"), ErrNoMethod))
+}
+
+func TestJSON2CodecBatch(t *T) {
+	rand := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	newReq := func(method string, args interface{}) Request {
+		p, err := json.Marshal(args)
+		require.Nil(t, err)
+		pp := json.RawMessage(p)
+		id := json.RawMessage(strconv.Itoa(rand.Int()))
+		return Request{
+			Method: method,
+			Params: &pp,
+			ID:     &id,
+		}
+	}
+
+	reqs := []Request{
+		newReq("Echo", "foo"),
+		newReq("Error1", "bar"),
+		newReq("Echo", "baz"),
+	}
+
+	body := new(bytes.Buffer)
+	require.Nil(t, json.NewEncoder(body).Encode(&reqs))
+	r, err := http.NewRequest("POST", "/", body)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var ress []Response
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&ress))
+	require.Len(t, ress, len(reqs))
+
+	byID := map[string]Response{}
+	for _, res := range ress {
+		byID[string(*res.ID)] = res
+	}
+
+	res := byID[string(*reqs[0].ID)]
+	assert.Equal(t, "foo", res.Result)
+
+	res = byID[string(*reqs[1].ID)]
+	assert.Equal(t, &Error{Code: ErrServer, Message: "some error"}, res.Error)
+
+	res = byID[string(*reqs[2].ID)]
+	assert.Equal(t, "baz", res.Result)
+}
+
+func TestJSON2CodecNotification(t *T) {
+	p := json.RawMessage(`"foo"`)
+	req := Request{Method: "Echo", Params: &p}
+
+	body := new(bytes.Buffer)
+	require.Nil(t, json.NewEncoder(body).Encode(&req))
+	r, err := http.NewRequest("POST", "/", body)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestJSON2CodecExplicitNullID(t *T) {
+	// an explicit "id":null is a valid, if discouraged, non-notification
+	// request, distinct from one which omits id entirely.
+	body := bytes.NewBufferString(`{"jsonrpc":"2.0","method":"Echo","params":"foo","id":null}`)
+	r, err := http.NewRequest("POST", "/", body)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	require.NotEqual(t, http.StatusNoContent, w.Code)
+
+	var res Response
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Nil(t, res.ID)
+	assert.Equal(t, "foo", res.Result)
+}
+
+func TestJSON2CodecBatchNotifications(t *T) {
+	p := json.RawMessage(`"foo"`)
+	notif := Request{Method: "Echo", Params: &p}
+	reqs := []Request{notif, notif}
+
+	body := new(bytes.Buffer)
+	require.Nil(t, json.NewEncoder(body).Encode(&reqs))
+	r, err := http.NewRequest("POST", "/", body)
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestJSON2CodecEmptyBatch(t *T) {
+	r, err := http.NewRequest("POST", "/", bytes.NewBufferString("[]"))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var res Response
+	require.Nil(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Equal(t, &Error{Code: ErrInvalidRequest, Message: "invalid Request"}, res.Error)
 }
@@ -7,12 +7,17 @@ package lrpchttp
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/levenlabs/lrpc"
 
 	"context"
 )
 
+// batchConcurrency is the maximum number of lrpc.Calls from a single batch
+// which will be run against the Handler concurrently.
+const batchConcurrency = 8
+
 // Codec describes a type which can translate an incoming http request into an
 // rpc request, and send back the response for the request
 type Codec interface {
@@ -30,6 +35,30 @@ type Codec interface {
 	Respond(lrpc.Call, interface{}) error
 }
 
+// BatchCodec is an optional interface a Codec may implement to support
+// handling multiple lrpc.Calls out of a single http.Request, as is done for
+// JSON RPC2 batch requests. If a Codec implements BatchCodec, HTTPHandler will
+// use NewBatchCall to check whether the incoming request is a batch before
+// falling back to the regular Codec interface.
+type BatchCodec interface {
+	Codec
+
+	// NewBatchCall is like NewCall, but it may return multiple lrpc.Calls
+	// decoded from a single http.Request. ok will be false if the request
+	// doesn't represent a batch, in which case NewCall should be used instead.
+	//
+	// NewBatchCall may return ok as true with zero calls and a nil error, in
+	// which case it's expected to have already written the full response to
+	// the http.ResponseWriter itself (e.g. for a batch which is empty, and
+	// therefore doesn't warrant dispatching anything to the Handler).
+	NewBatchCall(context.Context, http.ResponseWriter, *http.Request) (calls []lrpc.Call, ok bool, err error)
+
+	// RespondBatch is used to marshal and send back the responses
+	// corresponding to a set of lrpc.Calls previously returned from
+	// NewBatchCall. ress[i] is the response for calls[i].
+	RespondBatch(calls []lrpc.Call, ress []interface{}) error
+}
+
 // HTTPHandler takes a Codec which can translate http requests to rpc calls, and
 // a handler for those calls, and returns an http.Handler which puts it all
 // together.
@@ -42,6 +71,22 @@ func HTTPHandler(codec Codec, h lrpc.Handler) http.Handler {
 		ctx = context.WithValue(ctx, contextKeyRequest, r)
 		ctx = context.WithValue(ctx, contextKeyResponseWriter, w)
 
+		if bc, ok := codec.(BatchCodec); ok {
+			calls, isBatch, err := bc.NewBatchCall(ctx, w, r)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			} else if isBatch {
+				if len(calls) == 0 {
+					// NewBatchCall is expected to have already written the
+					// response for an empty batch.
+					return
+				}
+				serveBatch(w, bc, h, calls)
+				return
+			}
+		}
+
 		c, err := codec.NewCall(ctx, w, r)
 		if err != nil {
 			http.Error(w, err.Error(), 400)
@@ -58,6 +103,30 @@ func HTTPHandler(codec Codec, h lrpc.Handler) http.Handler {
 	})
 }
 
+// serveBatch runs every one of calls against h, with at most batchConcurrency
+// running at a time, and writes the results back using bc.RespondBatch.
+func serveBatch(w http.ResponseWriter, bc BatchCodec, h lrpc.Handler, calls []lrpc.Call) {
+	ress := make([]interface{}, len(calls))
+
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c lrpc.Call) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ress[i] = h.ServeRPC(c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	if err := bc.RespondBatch(calls, ress); err != nil {
+		// this probably won't ever go through, but might as well try
+		http.Error(w, err.Error(), 500)
+	}
+}
+
 type contextKey int
 
 const (
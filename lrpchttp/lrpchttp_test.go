@@ -7,7 +7,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	. "testing"
+	"time"
 
 	"context"
 
@@ -73,3 +77,86 @@ func TestHTTPHandler(t *T) {
 	assert.Equal(t, r, <-rCh)
 	assert.Equal(t, "foo:bar", w.Body.String())
 }
+
+// testBatchCodec extends testCodec with batch support. Only requests to
+// "/batch" are treated as a batch; the body is an integer giving the number of
+// calls to generate. Each call's response is joined with "," in the final
+// response body.
+type testBatchCodec struct {
+	testCodec
+}
+
+func (testBatchCodec) NewBatchCall(ctx context.Context, w http.ResponseWriter, r *http.Request) ([]lrpc.Call, bool, error) {
+	if r.URL.Path != "/batch" {
+		return nil, false, nil
+	}
+
+	bodyB, _ := ioutil.ReadAll(r.Body)
+	n, err := strconv.Atoi(string(bodyB))
+	if err != nil {
+		return nil, true, err
+	}
+
+	calls := make([]lrpc.Call, n)
+	for i := range calls {
+		calls[i] = testCodecCall{ctx, r}
+	}
+	return calls, true, nil
+}
+
+func (testBatchCodec) RespondBatch(calls []lrpc.Call, ress []interface{}) error {
+	w := ContextResponseWriter(calls[0].Context())
+	parts := make([]string, len(ress))
+	for i, res := range ress {
+		parts[i] = res.(string)
+	}
+	_, err := fmt.Fprint(w, strings.Join(parts, ","))
+	return err
+}
+
+func TestHTTPHandlerBatchConcurrencyCap(t *T) {
+	var current, maxSeen int32
+	h := HTTPHandler(testBatchCodec{}, lrpc.HandlerFunc(func(lrpc.Call) interface{} {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			old := atomic.LoadInt32(&maxSeen)
+			if n <= old || atomic.CompareAndSwapInt32(&maxSeen, old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		return "ok"
+	}))
+
+	const numCalls = batchConcurrency * 3
+	r, err := http.NewRequest("POST", "/batch", bytes.NewBufferString(strconv.Itoa(numCalls)))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, strings.Repeat("ok,", numCalls-1)+"ok", w.Body.String())
+	assert.True(t, atomic.LoadInt32(&maxSeen) <= batchConcurrency,
+		"saw %d calls running concurrently, want at most %d", maxSeen, batchConcurrency)
+	assert.Equal(t, int32(batchConcurrency), atomic.LoadInt32(&maxSeen),
+		"expected the batch to actually reach the concurrency cap")
+}
+
+func TestHTTPHandlerBatchCodecFallback(t *T) {
+	// a BatchCodec whose NewBatchCall reports a request isn't a batch should
+	// fall back to the plain Codec behavior, same as TestHTTPHandler.
+	h := HTTPHandler(testBatchCodec{}, lrpc.HandlerFunc(func(c lrpc.Call) interface{} {
+		var s string
+		if err := c.UnmarshalArgs(&s); err != nil {
+			return err
+		}
+		return c.Method() + ":" + s
+	}))
+
+	r, err := http.NewRequest("GET", "/foo", bytes.NewBufferString("bar"))
+	require.Nil(t, err)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, "foo:bar", w.Body.String())
+}
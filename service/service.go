@@ -0,0 +1,149 @@
+// Package service lets a Go struct's methods be registered as lrpc.Handlers by
+// reflection, similar to how net/rpc and gorilla/rpc expose services. This
+// removes the boilerplate of writing an lrpc.HandlerFunc for every method
+// which just wants to unmarshal its arguments into a typed struct.
+//
+// A method on the registered struct is eligible if it's exported and has one
+// of the following two signatures:
+//
+//	func(c lrpc.Call, args *ArgsT, reply *ReplyT) error
+//	func(ctx context.Context, args *ArgsT) (ReplyT, error)
+//
+// ArgsT and ReplyT may be any type. Methods which don't match either signature
+// are ignored.
+//
+//	type MyService struct{}
+//
+//	func (MyService) Echo(c lrpc.Call, args *string, reply *string) error {
+//		*reply = *args
+//		return nil
+//	}
+//
+//	set := service.New()
+//	set.Register(MyService{}, "MyService")
+//
+//	// set is an lrpc.Handler which will respond to the "MyService.Echo" method
+//	res := set.ServeRPC(lrpc.NewDirectCall(nil, "MyService.Echo", "hello"))
+//
+package service
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/levenlabs/lrpc"
+)
+
+var (
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfCall    = reflect.TypeOf((*lrpc.Call)(nil)).Elem()
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// Set is a collection of rpc methods registered via Register. It implements
+// the lrpc.Handler interface, dispatching an incoming Call to the registered
+// method matching its Method() name, or returning lrpc.ErrMethodNotFound.
+type Set struct {
+	mux lrpc.ServeMux
+}
+
+// New returns an initialized, empty Set.
+func New() *Set {
+	return &Set{mux: lrpc.ServeMux{}}
+}
+
+// ServeRPC implements the lrpc.Handler interface.
+func (s *Set) ServeRPC(c lrpc.Call) interface{} {
+	return s.mux.ServeRPC(c)
+}
+
+// Register inspects rcvr's method set by reflection and registers every
+// eligible method (see the package docstring for what's eligible) as an
+// lrpc.Handler under the method name "name.MethodName". It returns the Set so
+// calls can be chained.
+func (s *Set) Register(rcvr interface{}, name string) *Set {
+	v := reflect.ValueOf(rcvr)
+	t := v.Type()
+
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		mv := v.Method(i)
+
+		h := handlerForMethod(mv.Type(), mv)
+		if h == nil {
+			continue
+		}
+		s.mux.Handle(name+"."+m.Name, h)
+	}
+
+	return s
+}
+
+// handlerForMethod returns an lrpc.Handler which calls mv according to
+// whichever of the two eligible signatures mt describes, or nil if mt matches
+// neither.
+func handlerForMethod(mt reflect.Type, mv reflect.Value) lrpc.Handler {
+	switch {
+	case isNetRPCStyle(mt):
+		return netRPCStyleHandler(mt, mv)
+	case isCtxStyle(mt):
+		return ctxStyleHandler(mt, mv)
+	default:
+		return nil
+	}
+}
+
+// isNetRPCStyle returns true if mt is func(lrpc.Call, *ArgsT, *ReplyT) error
+func isNetRPCStyle(mt reflect.Type) bool {
+	return mt.NumIn() == 3 &&
+		mt.In(0) == typeOfCall &&
+		mt.In(1).Kind() == reflect.Ptr &&
+		mt.In(2).Kind() == reflect.Ptr &&
+		mt.NumOut() == 1 &&
+		mt.Out(0) == typeOfError
+}
+
+func netRPCStyleHandler(mt reflect.Type, mv reflect.Value) lrpc.Handler {
+	argsType := mt.In(1).Elem()
+	replyType := mt.In(2).Elem()
+
+	return lrpc.HandlerFunc(func(c lrpc.Call) interface{} {
+		args := reflect.New(argsType)
+		if err := c.UnmarshalArgs(args.Interface()); err != nil {
+			return err
+		}
+
+		reply := reflect.New(replyType)
+		out := mv.Call([]reflect.Value{reflect.ValueOf(c), args, reply})
+		if err, _ := out[0].Interface().(error); err != nil {
+			return err
+		}
+		return reply.Elem().Interface()
+	})
+}
+
+// isCtxStyle returns true if mt is func(context.Context, *ArgsT) (ReplyT, error)
+func isCtxStyle(mt reflect.Type) bool {
+	return mt.NumIn() == 2 &&
+		mt.In(0) == typeOfContext &&
+		mt.In(1).Kind() == reflect.Ptr &&
+		mt.NumOut() == 2 &&
+		mt.Out(1) == typeOfError
+}
+
+func ctxStyleHandler(mt reflect.Type, mv reflect.Value) lrpc.Handler {
+	argsType := mt.In(1).Elem()
+
+	return lrpc.HandlerFunc(func(c lrpc.Call) interface{} {
+		args := reflect.New(argsType)
+		if err := c.UnmarshalArgs(args.Interface()); err != nil {
+			return err
+		}
+
+		out := mv.Call([]reflect.Value{reflect.ValueOf(c.Context()), args})
+		if err, _ := out[1].Interface().(error); err != nil {
+			return err
+		}
+		return out[0].Interface()
+	})
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/levenlabs/lrpc"
+)
+
+type args struct {
+	A, B int
+}
+
+type netRPCStyleService struct{}
+
+func (netRPCStyleService) Add(c lrpc.Call, args *args, reply *int) error {
+	*reply = args.A + args.B
+	return nil
+}
+
+func (netRPCStyleService) Fail(c lrpc.Call, args *args, reply *int) error {
+	return errors.New("add failed")
+}
+
+// unexported, should never be registered
+func (netRPCStyleService) sub(c lrpc.Call, args *args, reply *int) error {
+	*reply = args.A - args.B
+	return nil
+}
+
+type ctxStyleService struct{}
+
+func (ctxStyleService) Add(ctx context.Context, args *args) (int, error) {
+	return args.A + args.B, nil
+}
+
+func (ctxStyleService) Fail(ctx context.Context, args *args) (int, error) {
+	return 0, errors.New("add failed")
+}
+
+func TestSetNetRPCStyle(t *T) {
+	set := New().Register(netRPCStyleService{}, "Math")
+
+	dc := lrpc.NewDirectCall(nil, "Math.Add", args{A: 1, B: 2})
+	assert.Equal(t, 3, set.ServeRPC(dc))
+
+	dc = lrpc.NewDirectCall(nil, "Math.Fail", args{A: 1, B: 2})
+	assert.Equal(t, errors.New("add failed"), set.ServeRPC(dc))
+
+	dc = lrpc.NewDirectCall(nil, "Math.sub", args{A: 1, B: 2})
+	assert.Equal(t, lrpc.ErrMethodNotFound, set.ServeRPC(dc))
+}
+
+func TestSetCtxStyle(t *T) {
+	set := New().Register(ctxStyleService{}, "Math")
+
+	dc := lrpc.NewDirectCall(nil, "Math.Add", args{A: 1, B: 2})
+	assert.Equal(t, 3, set.ServeRPC(dc))
+
+	dc = lrpc.NewDirectCall(nil, "Math.Fail", args{A: 1, B: 2})
+	assert.Equal(t, errors.New("add failed"), set.ServeRPC(dc))
+}